@@ -0,0 +1,22 @@
+package clients
+
+import "context"
+
+// FileRef identifies a single file discovered by SearchFiles
+type FileRef struct {
+	Name string
+	Path string
+	URL  string
+}
+
+// SourceClient looks up and fetches files from a source of vulnerability data
+// (GitHub, GitLab, a local filesystem, ...), so callers aren't tied to net/http
+// or any single source directly
+type SourceClient interface {
+	// SearchFiles finds candidate vulnerability data files in repo, optionally
+	// restricted to the given filenames
+	SearchFiles(ctx context.Context, repo string, filenames []string) ([]FileRef, error)
+
+	// FetchRaw downloads the raw content of a file found by SearchFiles
+	FetchRaw(ctx context.Context, ref FileRef) ([]byte, error)
+}
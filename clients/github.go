@@ -0,0 +1,233 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"souben/kai/logging"
+
+	"github.com/sirupsen/logrus"
+)
+
+func getEnvOrDefault(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvAsIntOrDefault(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var result int
+	fmt.Sscanf(value, "%d", &result)
+	return result
+}
+
+var (
+	GITHUB_API  = getEnvOrDefault("GITHUB_API", "https://api.github.com/search/code")
+	MAX_RETRIES = getEnvAsIntOrDefault("MAX_RETRIES", 2)
+)
+
+// githubClient is a SourceClient backed by the GitHub code search and contents APIs
+type githubClient struct {
+	token string
+	http  *http.Client
+}
+
+// NewGitHubClient creates a SourceClient authenticated with a GitHub API token
+func NewGitHubClient(token string) SourceClient {
+	return &githubClient{
+		token: token,
+		http:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type searchResponse struct {
+	Items []struct {
+		Name string `json:"name"`
+		Path string `json:"path"`
+		URL  string `json:"url"`
+	} `json:"items"`
+}
+
+// SearchFiles searches GitHub code search for JSON vulnerability data files in repoName
+func (c *githubClient) SearchFiles(ctx context.Context, repoName string, filenames []string) ([]FileRef, error) {
+	url := buildSearchURL(repoName, filenames)
+
+	var response searchResponse
+	var err error
+
+	for attempt := 0; attempt <= MAX_RETRIES; attempt++ {
+		if attempt > 0 {
+			logging.Log.WithField("attempt", attempt).Info("Retrying GitHub search request")
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+
+		req, reqErr := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if reqErr != nil {
+			err = reqErr
+			continue
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+
+		start := time.Now()
+		res, doErr := c.http.Do(req)
+		if doErr != nil {
+			err = doErr
+			continue
+		}
+
+		var body []byte
+		var wait time.Duration
+		body, wait, err = readWithRateLimit(res)
+
+		logging.Log.WithFields(logrus.Fields{
+			"attempt":     attempt,
+			"status_code": res.StatusCode,
+			"duration_ms": time.Since(start).Milliseconds(),
+		}).Debug("GitHub search request completed")
+
+		if err != nil {
+			if wait > 0 {
+				time.Sleep(wait)
+			}
+			continue
+		}
+
+		if err = json.Unmarshal(body, &response); err != nil {
+			continue
+		}
+
+		refs := make([]FileRef, len(response.Items))
+		for i, item := range response.Items {
+			refs[i] = FileRef{Name: item.Name, Path: item.Path, URL: item.URL}
+		}
+		return refs, nil
+	}
+
+	return nil, fmt.Errorf("failed to search GitHub files after %d attempts: %w", MAX_RETRIES+1, err)
+}
+
+// FetchRaw downloads the raw content of a file found by SearchFiles
+func (c *githubClient) FetchRaw(ctx context.Context, ref FileRef) ([]byte, error) {
+	var err error
+
+	for attempt := 0; attempt <= MAX_RETRIES; attempt++ {
+		if attempt > 0 {
+			logging.Log.WithFields(logrus.Fields{"file": ref.Path, "attempt": attempt}).Info("Retrying GitHub file download")
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+
+		req, reqErr := http.NewRequestWithContext(ctx, "GET", ref.URL, nil)
+		if reqErr != nil {
+			err = reqErr
+			continue
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+		// The GitHub API returns JSON with the content base64-encoded; this header asks
+		// for the raw file content instead.
+		req.Header.Set("Accept", "application/vnd.github.v3.raw")
+
+		start := time.Now()
+		res, doErr := c.http.Do(req)
+		if doErr != nil {
+			err = doErr
+			continue
+		}
+
+		var body []byte
+		var wait time.Duration
+		body, wait, err = readWithRateLimit(res)
+
+		logging.Log.WithFields(logrus.Fields{
+			"file":        ref.Path,
+			"attempt":     attempt,
+			"status_code": res.StatusCode,
+			"duration_ms": time.Since(start).Milliseconds(),
+		}).Debug("GitHub file download completed")
+
+		if err != nil {
+			if wait > 0 {
+				time.Sleep(wait)
+			}
+			continue
+		}
+
+		return body, nil
+	}
+
+	return nil, fmt.Errorf("failed to download file after %d attempts: %w", MAX_RETRIES+1, err)
+}
+
+// readWithRateLimit reads res's body, honoring GitHub's rate-limit headers: if the
+// response indicates the rate limit was exhausted, it returns how long to back off
+// before retrying alongside the error.
+func readWithRateLimit(res *http.Response) ([]byte, time.Duration, error) {
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusForbidden && res.Header.Get("X-RateLimit-Remaining") == "0" {
+		return nil, rateLimitWait(res), fmt.Errorf("GitHub API rate limit exceeded")
+	}
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		return nil, rateLimitWait(res), fmt.Errorf("GitHub API returned status %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("GitHub API returned status %d: %s", res.StatusCode, string(data))
+	}
+
+	return data, 0, nil
+}
+
+// rateLimitWait determines how long to back off, preferring Retry-After and falling
+// back to X-RateLimit-Reset
+func rateLimitWait(res *http.Response) time.Duration {
+	if retryAfter := res.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	if reset := res.Header.Get("X-RateLimit-Reset"); reset != "" {
+		if unix, err := strconv.ParseInt(reset, 10, 64); err == nil {
+			if wait := time.Until(time.Unix(unix, 0)); wait > 0 {
+				return wait
+			}
+		}
+	}
+
+	return time.Second
+}
+
+// buildSearchURL constructs the URL for the GitHub code search API call
+func buildSearchURL(repoName string, filenames []string) string {
+	url := fmt.Sprintf("%s?q=repo:%s", GITHUB_API, repoName)
+
+	// If filenames were provided, search exactly for those files
+	for _, filename := range filenames {
+		url = fmt.Sprintf("%s+filename:%s.json", url, filename)
+	}
+
+	// If no filenames are provided, search for all JSON files within the repository
+	if len(filenames) == 0 {
+		url = fmt.Sprintf("%s+extension:json", url)
+	}
+
+	return url
+}
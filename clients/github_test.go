@@ -0,0 +1,112 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test buildSearchURL function
+func TestBuildSearchURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		repo     string
+		files    []string
+		expected string
+	}{
+		{
+			name:     "With repo and files",
+			repo:     "owner/repo",
+			files:    []string{"file1", "file2"},
+			expected: "https://api.github.com/search/code?q=repo:owner/repo+filename:file1.json+filename:file2.json",
+		},
+		{
+			name:     "With repo but no files",
+			repo:     "owner/repo",
+			files:    []string{},
+			expected: "https://api.github.com/search/code?q=repo:owner/repo+extension:json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := buildSearchURL(tt.repo, tt.files)
+			if result != tt.expected {
+				t.Errorf("buildSearchURL() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+// setupMockGitHubServer serves the code search and raw file responses githubClient expects
+func setupMockGitHubServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/search/code" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{
+				"total_count": 1,
+				"items": [
+					{
+						"name": "test.json",
+						"path": "test.json",
+						"url": "/raw/test.json"
+					}
+				]
+			}`))
+			return
+		}
+
+		if r.URL.Path == "/raw/test.json" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`[{"scanResults": {"vulnerabilities": []}}]`))
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+// Test SearchFiles
+func TestGithubClientSearchFiles(t *testing.T) {
+	server := setupMockGitHubServer()
+	defer server.Close()
+
+	originalAPI := GITHUB_API
+	GITHUB_API = server.URL + "/search/code"
+	defer func() { GITHUB_API = originalAPI }()
+
+	client := NewGitHubClient("test-token")
+
+	items, err := client.SearchFiles(context.Background(), "test/repo", nil)
+	if err != nil {
+		t.Fatalf("SearchFiles() error = %v", err)
+	}
+
+	if len(items) != 1 {
+		t.Fatalf("SearchFiles() returned %d items, want 1", len(items))
+	}
+
+	if items[0].Name != "test.json" {
+		t.Errorf("SearchFiles() item name = %s, want test.json", items[0].Name)
+	}
+}
+
+// Test FetchRaw
+func TestGithubClientFetchRaw(t *testing.T) {
+	server := setupMockGitHubServer()
+	defer server.Close()
+
+	client := NewGitHubClient("test-token")
+
+	data, err := client.FetchRaw(context.Background(), FileRef{Path: "test.json", URL: server.URL + "/raw/test.json"})
+	if err != nil {
+		t.Fatalf("FetchRaw() error = %v", err)
+	}
+
+	if len(data) == 0 {
+		t.Error("FetchRaw() returned empty data")
+	}
+}
@@ -0,0 +1,22 @@
+package controller
+
+import (
+	"net/http"
+
+	"souben/kai/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health handles the GET /health endpoint, used for log aggregation and Kubernetes
+// readiness probes
+func Health(c *gin.Context) {
+	status, healthy := service.Health(c.Request.Context())
+
+	if !healthy {
+		c.JSON(http.StatusServiceUnavailable, status)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
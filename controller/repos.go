@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"souben/kai/repo"
+	"souben/kai/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DEFAULT_TRACK_INTERVAL is used when a POST /repos request doesn't specify one
+const DEFAULT_TRACK_INTERVAL = time.Hour
+
+// AddRepo handles the POST /repos endpoint, registering a repo for periodic re-scanning
+func AddRepo(c *gin.Context) {
+	var reqBody repo.TrackRepoRequestBody
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+		return
+	}
+
+	if reqBody.Repo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Repository name is required"})
+		return
+	}
+
+	interval := DEFAULT_TRACK_INTERVAL
+	if reqBody.IntervalSeconds > 0 {
+		interval = time.Duration(reqBody.IntervalSeconds) * time.Second
+	}
+
+	ctx := context.Background()
+	if err := service.TrackRepo(ctx, reqBody.Repo, reqBody.Files, interval); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to track repository: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"repo": reqBody.Repo, "interval_seconds": int(interval.Seconds())})
+}
+
+// RemoveRepo handles the DELETE /repos/*name endpoint, removing a repo from the updater's schedule.
+// name is matched as a wildcard (not a single gin path segment) since repo names are
+// "owner/repo" and contain a slash.
+func RemoveRepo(c *gin.Context) {
+	repoName := strings.TrimPrefix(c.Param("name"), "/")
+	if repoName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Repository name is required"})
+		return
+	}
+
+	ctx := context.Background()
+	if err := service.UntrackRepo(ctx, repoName); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to untrack repository: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"repo": repoName})
+}
+
+// ListRepos handles the GET /repos endpoint, listing every repo tracked by the updater
+func ListRepos(c *gin.Context) {
+	ctx := context.Background()
+
+	tracked, err := service.ListTrackedRepos(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list repositories: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tracked)
+}
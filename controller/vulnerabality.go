@@ -2,22 +2,30 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"souben/kai/repo"
 	"souben/kai/service"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Scan handles the POST /scan endpoint
+// heartbeatInterval is how often ScanEvents writes a comment to keep proxies from
+// closing the SSE connection while a scan is still running
+const heartbeatInterval = 15 * time.Second
+
+// Scan handles the POST /scan endpoint, starting the scan in the background and
+// returning its scan_id immediately. Clients track progress via GET /scan/:id or
+// GET /scan/:id/events
 func Scan(c *gin.Context) {
 	var reqBody repo.ScanRequestBody
 
 	// Parse the request body
 	if err := c.BindJSON(&reqBody); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
 		return
-    }
+	}
 
 	// Validate the request
 	if reqBody.Repo == "" {
@@ -25,37 +33,101 @@ func Scan(c *gin.Context) {
 		return
 	}
 
-	// Call the service to scan the repository
-	results, err := service.Scan(reqBody.Repo, reqBody.Files)
+	job, err := service.StartScan(reqBody.Repo, reqBody.Files)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Scan failed: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, results)
+	c.JSON(http.StatusAccepted, gin.H{"scan_id": job.ID})
+}
+
+// ScanStatus handles the GET /scan/:id endpoint, reporting whether the scan is
+// still running and, once it isn't, its result or error
+func ScanStatus(c *gin.Context) {
+	job, ok := service.GetScanJob(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scan not found"})
+		return
+	}
+
+	status, result, scanErr := job.Status()
+	body := gin.H{"scan_id": job.ID, "status": status}
+	if result != nil {
+		body["result"] = result
+	}
+	if scanErr != "" {
+		body["error"] = scanErr
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+// ScanEvents handles the GET /scan/:id/events endpoint, streaming the scan's
+// ProgressEvents as Server-Sent Events until it finishes
+func ScanEvents(c *gin.Context) {
+	job, ok := service.GetScanJob(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scan not found"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, open := <-job.Progress.Events():
+			if !open {
+				status, result, scanErr := job.Status()
+				c.SSEvent("done", gin.H{"status": status, "result": result, "error": scanErr})
+				c.Writer.Flush()
+				return
+			}
+			c.SSEvent("progress", event)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
 }
 
-// Query handles the endpoint /query 
-func Query(c *gin.Context){
+// Query handles the endpoint /query
+func Query(c *gin.Context) {
 	// Define a body struct to store the request body
 	var body repo.QueryBody
 	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Query Failed: "+ err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query Failed: " + err.Error()})
 		return
-	}	
+	}
 
-	if body.Filters.Severity == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Severity must be a valid non-empty string"})
-		return
+	// Limit is a client-facing pagination size, not the internal "no limit" escape
+	// hatch some callers (e.g. the updater) use directly against the repo layer.
+	// A negative limit must never reach GetVulnerabilities from here.
+	if body.Filters.Limit < 0 {
+		body.Filters.Limit = 0
 	}
 
 	ctx := context.Background()
 
-	// Now, Let's fetch the vulnerabilities based on the severity filter
-	vulnerabilities, err := service.Filter(ctx, body.Filters.Severity)
+	// Now, Let's fetch the vulnerabilities matching the filter
+	vulnerabilities, total, err := service.Filter(ctx, body.Filters)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusAccepted, vulnerabilities)
-}
\ No newline at end of file
+	result := repo.QueryResult{Results: vulnerabilities, Total: total}
+	if offset := body.Filters.Offset + len(vulnerabilities); offset < total {
+		result.NextOffset = &offset
+	}
+
+	c.JSON(http.StatusAccepted, result)
+}
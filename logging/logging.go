@@ -0,0 +1,42 @@
+// Package logging provides the single logrus instance shared by every package
+// (service, clients, ...) so they all honor the same LOG_FORMAT/LOG_LEVEL
+// configuration instead of some of them falling back to logrus's unconfigured
+// default logger.
+package logging
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Log is the process-wide structured logger, configured from LOG_FORMAT and LOG_LEVEL
+var Log = logrus.New()
+
+func init() {
+	Configure()
+}
+
+// Configure sets up Log's formatter (LOG_FORMAT=json|text) and level
+// (LOG_LEVEL=debug|info|warn|error) from the environment
+func Configure() {
+	if getEnvOrDefault("LOG_FORMAT", "text") == "json" {
+		Log.SetFormatter(&logrus.JSONFormatter{})
+	} else {
+		Log.SetFormatter(&logrus.TextFormatter{})
+	}
+
+	level, err := logrus.ParseLevel(getEnvOrDefault("LOG_LEVEL", "info"))
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	Log.SetLevel(level)
+}
+
+func getEnvOrDefault(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
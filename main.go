@@ -3,8 +3,10 @@ package main
 import (
 	"log"
 	"os"
+	"os/signal"
 	"souben/kai/controller"
 	"souben/kai/service"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,12 +19,24 @@ func main() {
 	}
 	defer service.CloseDatabase()
 
+	// Start the background updater that re-scans tracked repositories
+	updaterStopper, err := service.StartUpdater()
+	if err != nil {
+		log.Fatalf("Failed to start updater: %v", err)
+	}
+
 	// Set up Gin router
 	r := gin.Default()
 
 	// Define routes
 	r.POST("/scan", controller.Scan)
+	r.GET("/scan/:id", controller.ScanStatus)
+	r.GET("/scan/:id/events", controller.ScanEvents)
 	r.POST("/query", controller.Query)
+	r.POST("/repos", controller.AddRepo)
+	r.DELETE("/repos/*name", controller.RemoveRepo)
+	r.GET("/repos", controller.ListRepos)
+	r.GET("/health", controller.Health)
 
 	// Get port from environment or use default
 	port := os.Getenv("PORT")
@@ -30,9 +44,18 @@ func main() {
 		port = "8080"
 	}
 
-	// Start the server
-	log.Printf("Starting server on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
+	// Run the server in the background so we can catch SIGINT/SIGTERM and stop the updater
+	go func() {
+		log.Printf("Starting server on port %s", port)
+		if err := r.Run(":" + port); err != nil {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	log.Print("Shutting down, stopping updater...")
+	updaterStopper.Stop()
 }
@@ -4,8 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	"souben/kai/logging"
+
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
 )
@@ -29,6 +32,53 @@ type Vulnerabality struct {
 	ScanTime   time.Time `json:"scan_time"`
 }
 
+// EnrichmentRecord caches the fields a VulnSource filled in for a given
+// vulnerability/package/version so repeated scans don't hit the upstream API again.
+type EnrichmentRecord struct {
+	ID             string
+	PackageName    string
+	CurrentVersion string
+	Cvss           float64
+	PublishedDate  time.Time
+	FixedVersion   string
+	Link           string
+	RiskFactors    []string
+	FetchedAt      time.Time
+}
+
+// VulnerabilityFilter describes every field GetVulnerabilities can filter, sort and
+// paginate by. SourceRepo matches against the stored source_file, since that is the
+// only repo-identifying column vulnerabilities are currently tagged with.
+type VulnerabilityFilter struct {
+	Severity        []string   `json:"severity"`
+	CvssMin         *float64   `json:"cvss_min"`
+	CvssMax         *float64   `json:"cvss_max"`
+	PackageName     string     `json:"package_name"`
+	PublishedAfter  *time.Time `json:"published_after"`
+	PublishedBefore *time.Time `json:"published_before"`
+	Status          string     `json:"status"`
+	SourceRepo      string     `json:"source_repo"`
+	HasFix          *bool      `json:"has_fix"`
+	RiskFactors     []string   `json:"risk_factors"`
+	SortBy          string     `json:"sort_by"`
+	Order           string     `json:"order"`
+	// Limit is the page size; 0 defaults to 50. A negative Limit means "no limit"
+	// (every match, unpaginated) and is meant for internal callers only (e.g. the
+	// updater diffing the full HIGH/CRITICAL set) — the /query controller clamps
+	// any client-supplied negative Limit to 0 before it reaches GetVulnerabilities.
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// TrackedRepo is a repository the updater re-scans on a schedule
+type TrackedRepo struct {
+	Repo     string    `json:"repo"`
+	Files    []string  `json:"files"`
+	Interval int       `json:"interval_seconds"`
+	LastScan time.Time `json:"last_scan"`
+	NextScan time.Time `json:"next_scan"`
+}
+
 // DatabaseConfig holds the configuration for the database connection
 type DatabaseConfig struct {
 	Host     string
@@ -75,6 +125,11 @@ func (r *PostgresRepo) Close() error {
 	return r.db.Close()
 }
 
+// Ping checks that the database connection is alive, for use by readiness probes
+func (r *PostgresRepo) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
 // SaveVulnerabilities stores a batch of vulnerabilities in the database
 func (r *PostgresRepo) SaveVulnerabilities(ctx context.Context, vulnerabilities []Vulnerabality) error {
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -123,20 +178,46 @@ func (r *PostgresRepo) SaveVulnerabilities(ctx context.Context, vulnerabilities
 	return nil
 }
 
-// GetVulnerabilities retrieves vulnerabilities from the database
-func (r *PostgresRepo) GetVulnerabilities(ctx context.Context, severity string) ([]Vulnerabality, error) {
-	query := `
-		SELECT id, severity, cvss, status, package_name, current_version, 
-			fixed_version, description, published_date, link, risk_factors, 
-			source_file, scan_time 
-		FROM vulnerabilities 
-		WHERE severity=$1 
-		ORDER BY scan_time DESC 
-	`
+// GetVulnerabilities retrieves vulnerabilities matching filter from the database,
+// along with the total number of matches (ignoring limit/offset) so callers can paginate
+func (r *PostgresRepo) GetVulnerabilities(ctx context.Context, filter VulnerabilityFilter) ([]Vulnerabality, int, error) {
+	where, args := buildVulnerabilityFilter(filter)
 
-	rows, err := r.db.QueryContext(ctx, query, severity)
+	var total int
+	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM vulnerabilities %s`, where)
+	if err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// A negative limit means "no limit", e.g. for internal callers that need every match
+	limitOffset := ""
+	queryArgs := args
+	if filter.Limit >= 0 {
+		limit := filter.Limit
+		if limit == 0 {
+			limit = 50
+		}
+		offset := filter.Offset
+		if offset < 0 {
+			offset = 0
+		}
+		limitOffset = fmt.Sprintf("LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		queryArgs = append(args, limit, offset)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, severity, cvss, status, package_name, current_version,
+			fixed_version, description, published_date, link, risk_factors,
+			source_file, scan_time
+		FROM vulnerabilities
+		%s
+		%s
+		%s
+	`, where, orderByClause(filter.SortBy, filter.Order), limitOffset)
+
+	rows, err := r.db.QueryContext(ctx, query, queryArgs...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -150,12 +231,291 @@ func (r *PostgresRepo) GetVulnerabilities(ctx context.Context, severity string)
 			&vuln.Link, pq.Array(&vuln.RiskFactors), &vuln.SourceFile, &vuln.ScanTime,
 		)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		vulnerabilities = append(vulnerabilities, vuln)
 	}
 
-	return vulnerabilities, nil
+	return vulnerabilities, total, nil
+}
+
+// sortableColumns whitelists the columns sort_by may reference, since it can't be
+// passed as a placeholder like the other filter values
+var sortableColumns = map[string]bool{
+	"severity": true, "cvss": true, "published_date": true, "scan_time": true, "package_name": true,
+}
+
+// orderByClause builds an ORDER BY clause from sort_by/order, falling back to
+// scan_time for a sort_by not in sortableColumns and to DESC for any order other
+// than "asc" (case-insensitive)
+func orderByClause(sortBy, order string) string {
+	if !sortableColumns[sortBy] {
+		sortBy = "scan_time"
+	}
+
+	order = strings.ToUpper(order)
+	if order != "ASC" {
+		order = "DESC"
+	}
+
+	return fmt.Sprintf("ORDER BY %s %s", sortBy, order)
+}
+
+// escapeLikePattern escapes the characters ILIKE treats specially as a literal
+// match (the default escape character itself, and the % and _ wildcards) so a
+// PackageName like "foo_bar" doesn't also match "fooXbar" via ILIKE's single-char
+// wildcard. It runs before PackageName's own "*" glob syntax is turned into "%".
+func escapeLikePattern(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "%", `\%`)
+	s = strings.ReplaceAll(s, "_", `\_`)
+	return s
+}
+
+// buildVulnerabilityFilter builds a parameterized WHERE clause and its argument list
+// from filter. Every value is passed as a $N placeholder so filter input can never be
+// interpolated directly into the query.
+func buildVulnerabilityFilter(filter VulnerabilityFilter) (string, []interface{}) {
+	var (
+		clauses []string
+		args    []interface{}
+	)
+
+	add := func(clauseFmt string, value interface{}) {
+		args = append(args, value)
+		clauses = append(clauses, fmt.Sprintf(clauseFmt, len(args)))
+	}
+
+	if len(filter.Severity) > 0 {
+		add("severity = ANY($%d)", pq.Array(filter.Severity))
+	}
+	if filter.CvssMin != nil {
+		add("cvss >= $%d", *filter.CvssMin)
+	}
+	if filter.CvssMax != nil {
+		add("cvss <= $%d", *filter.CvssMax)
+	}
+	if filter.PackageName != "" {
+		add("package_name ILIKE $%d", strings.ReplaceAll(escapeLikePattern(filter.PackageName), "*", "%"))
+	}
+	if filter.PublishedAfter != nil {
+		add("published_date >= $%d", *filter.PublishedAfter)
+	}
+	if filter.PublishedBefore != nil {
+		add("published_date <= $%d", *filter.PublishedBefore)
+	}
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+	if filter.SourceRepo != "" {
+		add("source_file ILIKE $%d", filter.SourceRepo+"%")
+	}
+	if filter.HasFix != nil {
+		if *filter.HasFix {
+			clauses = append(clauses, "fixed_version IS NOT NULL AND fixed_version <> ''")
+		} else {
+			clauses = append(clauses, "(fixed_version IS NULL OR fixed_version = '')")
+		}
+	}
+	if len(filter.RiskFactors) > 0 {
+		add("risk_factors && $%d", pq.Array(filter.RiskFactors))
+	}
+
+	if len(clauses) == 0 {
+		return "", args
+	}
+
+	return "WHERE " + strings.Join(clauses, " AND "), args
+}
+
+// GetCachedEnrichment returns a cached enrichment record if one exists and is
+// younger than ttl, so callers can skip hitting the upstream vulnerability source.
+func (r *PostgresRepo) GetCachedEnrichment(ctx context.Context, id, packageName, currentVersion string, ttl time.Duration) (*EnrichmentRecord, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT id, package_name, current_version, cvss, published_date,
+			fixed_version, link, risk_factors, fetched_at
+		FROM enrichment_cache
+		WHERE id=$1 AND package_name=$2 AND current_version=$3 AND fetched_at > $4
+	`, id, packageName, currentVersion, time.Now().Add(-ttl))
+
+	var rec EnrichmentRecord
+	err := row.Scan(
+		&rec.ID, &rec.PackageName, &rec.CurrentVersion, &rec.Cvss, &rec.PublishedDate,
+		&rec.FixedVersion, &rec.Link, pq.Array(&rec.RiskFactors), &rec.FetchedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+// SaveCachedEnrichment upserts an enrichment record, refreshing fetched_at so the TTL
+// is measured from the most recent lookup.
+func (r *PostgresRepo) SaveCachedEnrichment(ctx context.Context, rec EnrichmentRecord) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO enrichment_cache (
+			id, package_name, current_version, cvss, published_date,
+			fixed_version, link, risk_factors, fetched_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9
+		) ON CONFLICT (id, package_name, current_version)
+		DO UPDATE SET
+			cvss = $4, published_date = $5, fixed_version = $6,
+			link = $7, risk_factors = $8, fetched_at = $9
+	`, rec.ID, rec.PackageName, rec.CurrentVersion, rec.Cvss, rec.PublishedDate,
+		rec.FixedVersion, rec.Link, pq.Array(rec.RiskFactors), rec.FetchedAt,
+	)
+
+	return err
+}
+
+// SaveTrackedRepo registers a repository for periodic re-scanning, or updates its
+// schedule if it is already tracked
+func (r *PostgresRepo) SaveTrackedRepo(ctx context.Context, tracked TrackedRepo) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO tracked_repos (repo, files, interval_seconds, last_scan, next_scan)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (repo)
+		DO UPDATE SET files = $2, interval_seconds = $3, next_scan = $5
+	`, tracked.Repo, pq.Array(tracked.Files), tracked.Interval, tracked.LastScan, tracked.NextScan)
+
+	return err
+}
+
+// DeleteTrackedRepo stops a repository from being periodically re-scanned
+func (r *PostgresRepo) DeleteTrackedRepo(ctx context.Context, repoName string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM tracked_repos WHERE repo=$1`, repoName)
+	return err
+}
+
+// ListTrackedRepos returns every repository currently tracked by the updater
+func (r *PostgresRepo) ListTrackedRepos(ctx context.Context) ([]TrackedRepo, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT repo, files, interval_seconds, last_scan, next_scan FROM tracked_repos
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracked []TrackedRepo
+	for rows.Next() {
+		var t TrackedRepo
+		if err := rows.Scan(&t.Repo, pq.Array(&t.Files), &t.Interval, &t.LastScan, &t.NextScan); err != nil {
+			return nil, err
+		}
+		tracked = append(tracked, t)
+	}
+
+	return tracked, nil
+}
+
+// UpdateTrackedRepoSchedule records that a repo was just re-scanned and schedules its next run
+func (r *PostgresRepo) UpdateTrackedRepoSchedule(ctx context.Context, repoName string, lastScan, nextScan time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE tracked_repos SET last_scan=$2, next_scan=$3 WHERE repo=$1
+	`, repoName, lastScan, nextScan)
+
+	return err
+}
+
+const (
+	// scanLockLease is how long an acquired scan lock is valid before it's considered
+	// abandoned and can be reclaimed by another replica
+	scanLockLease = 2 * time.Minute
+	// scanLockRenewInterval is how often a held lock's lease is extended while fn runs
+	scanLockRenewInterval = scanLockLease / 2
+)
+
+// WithScanLock runs fn while holding a time-leased lock on repoName, so that only one
+// updater replica re-scans a given repo at a time. Unlike wrapping fn in a single
+// long-held transaction, the lease is acquired, renewed and released with short,
+// independent statements, so a multi-minute scan doesn't pin a Postgres connection or
+// an open transaction (and its row lock) for its entire duration.
+func (r *PostgresRepo) WithScanLock(ctx context.Context, repoName string, fn func(ctx context.Context) error) error {
+	acquired, err := r.acquireScanLock(ctx, repoName)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return fmt.Errorf("scan lock for %s is already held", repoName)
+	}
+
+	renewCtx, stopRenewing := context.WithCancel(ctx)
+	renewDone := make(chan struct{})
+	go func() {
+		defer close(renewDone)
+		r.renewScanLockUntilDone(renewCtx, repoName)
+	}()
+
+	err = fn(ctx)
+
+	stopRenewing()
+	<-renewDone
+
+	if releaseErr := r.releaseScanLock(context.Background(), repoName); releaseErr != nil {
+		logging.Log.WithField("repo", repoName).WithError(releaseErr).Warn("failed to release scan lock")
+		if err == nil {
+			err = releaseErr
+		}
+	}
+
+	return err
+}
+
+// acquireScanLock claims repoName's lock row if it's unclaimed or its lease has
+// expired, returning whether the lock was acquired
+func (r *PostgresRepo) acquireScanLock(ctx context.Context, repoName string) (bool, error) {
+	if _, err := r.db.ExecContext(ctx, `
+		INSERT INTO scan_locks (repo, locked_until) VALUES ($1, '1970-01-01') ON CONFLICT (repo) DO NOTHING
+	`, repoName); err != nil {
+		return false, err
+	}
+
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE scan_locks SET locked_until = $2 WHERE repo = $1 AND locked_until < now()
+	`, repoName, time.Now().Add(scanLockLease))
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// renewScanLockUntilDone extends repoName's lease every scanLockRenewInterval until
+// ctx is cancelled, so a scan that outlives a single lease doesn't lose the lock
+func (r *PostgresRepo) renewScanLockUntilDone(ctx context.Context, repoName string) {
+	ticker := time.NewTicker(scanLockRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.db.ExecContext(ctx, `
+				UPDATE scan_locks SET locked_until = $2 WHERE repo = $1
+			`, repoName, time.Now().Add(scanLockLease)); err != nil {
+				logging.Log.WithField("repo", repoName).WithError(err).Warn("failed to renew scan lock")
+			}
+		}
+	}
+}
+
+// releaseScanLock expires repoName's lease immediately so another replica can
+// acquire it without waiting out the rest of scanLockLease
+func (r *PostgresRepo) releaseScanLock(ctx context.Context, repoName string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE scan_locks SET locked_until = now() WHERE repo = $1`, repoName)
+	return err
 }
 
 // initSchema creates the necessary database tables if they don't exist
@@ -178,6 +538,58 @@ func initSchema(db *sql.DB) error {
 			PRIMARY KEY (id, source_file)
 		)
 	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_vulnerabilities_severity ON vulnerabilities (severity);
+		CREATE INDEX IF NOT EXISTS idx_vulnerabilities_package_name ON vulnerabilities (package_name);
+		CREATE INDEX IF NOT EXISTS idx_vulnerabilities_cvss ON vulnerabilities (cvss);
+		CREATE INDEX IF NOT EXISTS idx_vulnerabilities_published_date ON vulnerabilities (published_date);
+		CREATE INDEX IF NOT EXISTS idx_vulnerabilities_risk_factors ON vulnerabilities USING GIN (risk_factors);
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS enrichment_cache (
+			id VARCHAR(255) NOT NULL,
+			package_name VARCHAR(255) NOT NULL,
+			current_version VARCHAR(50) NOT NULL,
+			cvss DECIMAL(4,1),
+			published_date TIMESTAMP,
+			fixed_version VARCHAR(50),
+			link TEXT,
+			risk_factors TEXT[],
+			fetched_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (id, package_name, current_version)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS tracked_repos (
+			repo VARCHAR(255) PRIMARY KEY,
+			files TEXT[],
+			interval_seconds INTEGER NOT NULL,
+			last_scan TIMESTAMP,
+			next_scan TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS scan_locks (
+			repo VARCHAR(255) PRIMARY KEY,
+			locked_until TIMESTAMP NOT NULL DEFAULT '1970-01-01'
+		)
+	`)
 
 	return err
 }
@@ -0,0 +1,107 @@
+package repo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestBuildVulnerabilityFilterNoFilters(t *testing.T) {
+	where, args := buildVulnerabilityFilter(VulnerabilityFilter{})
+	if where != "" {
+		t.Errorf("buildVulnerabilityFilter() where = %q, want empty", where)
+	}
+	if len(args) != 0 {
+		t.Errorf("buildVulnerabilityFilter() args = %v, want none", args)
+	}
+}
+
+// Test that has_fix, which contributes a clause but no placeholder, doesn't throw
+// off the $N numbering of the filter added after it
+func TestBuildVulnerabilityFilterSeverityRiskFactorsHasFix(t *testing.T) {
+	hasFix := true
+	filter := VulnerabilityFilter{
+		Severity:    []string{"HIGH", "CRITICAL"},
+		RiskFactors: []string{"network-exposed"},
+		HasFix:      &hasFix,
+	}
+
+	where, args := buildVulnerabilityFilter(filter)
+
+	wantWhere := "WHERE severity = ANY($1) AND fixed_version IS NOT NULL AND fixed_version <> '' AND risk_factors && $2"
+	if where != wantWhere {
+		t.Errorf("buildVulnerabilityFilter() where = %q, want %q", where, wantWhere)
+	}
+
+	if len(args) != 2 {
+		t.Fatalf("buildVulnerabilityFilter() args = %v, want 2 (has_fix takes no placeholder)", args)
+	}
+	if !reflect.DeepEqual(args[0], pq.Array(filter.Severity)) {
+		t.Errorf("buildVulnerabilityFilter() args[0] (=$1) = %v, want the severity array", args[0])
+	}
+	if !reflect.DeepEqual(args[1], pq.Array(filter.RiskFactors)) {
+		t.Errorf("buildVulnerabilityFilter() args[1] (=$2) = %v, want the risk_factors array", args[1])
+	}
+}
+
+// Test $N numbering across a mix of placeholder and non-placeholder filters
+func TestBuildVulnerabilityFilterPlaceholderNumbering(t *testing.T) {
+	cvssMin := 7.0
+	filter := VulnerabilityFilter{
+		Severity:    []string{"HIGH"},
+		CvssMin:     &cvssMin,
+		PackageName: "left-pad",
+		RiskFactors: []string{"supply-chain"},
+	}
+
+	where, args := buildVulnerabilityFilter(filter)
+
+	wantWhere := "WHERE severity = ANY($1) AND cvss >= $2 AND package_name ILIKE $3 AND risk_factors && $4"
+	if where != wantWhere {
+		t.Errorf("buildVulnerabilityFilter() where = %q, want %q", where, wantWhere)
+	}
+	if len(args) != 4 {
+		t.Fatalf("buildVulnerabilityFilter() args = %v, want 4", args)
+	}
+}
+
+// Test that literal % and _ in PackageName are escaped before * is turned into the
+// ILIKE wildcard, so they can't be mistaken for ILIKE wildcards themselves
+func TestBuildVulnerabilityFilterPackageNameEscapesLiteralWildcards(t *testing.T) {
+	filter := VulnerabilityFilter{PackageName: "foo_bar*"}
+
+	where, args := buildVulnerabilityFilter(filter)
+
+	if where != "WHERE package_name ILIKE $1" {
+		t.Errorf("buildVulnerabilityFilter() where = %q", where)
+	}
+	if len(args) != 1 {
+		t.Fatalf("buildVulnerabilityFilter() args = %v, want 1", args)
+	}
+
+	want := `foo\_bar%`
+	if args[0] != want {
+		t.Errorf("buildVulnerabilityFilter() args[0] = %q, want %q", args[0], want)
+	}
+}
+
+func TestOrderByClause(t *testing.T) {
+	tests := []struct {
+		name, sortBy, order, want string
+	}{
+		{"known column ascending", "cvss", "asc", "ORDER BY cvss ASC"},
+		{"known column default direction", "severity", "", "ORDER BY severity DESC"},
+		{"unknown column falls back to scan_time", "'; DROP TABLE vulnerabilities; --", "asc", "ORDER BY scan_time ASC"},
+		{"empty sort_by falls back to scan_time", "", "", "ORDER BY scan_time DESC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := orderByClause(tt.sortBy, tt.order)
+			if got != tt.want {
+				t.Errorf("orderByClause(%q, %q) = %q, want %q", tt.sortBy, tt.order, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,16 +2,6 @@ package repo
 
 import "time"
 
-// SearchResponse represents the response from GitHub API search
-type SearchResponse struct {
-	TotalCount int `json:"total_count"`
-	Items      []struct {
-		Name string `json:"name"`
-		Path string `json:"path"`
-		URL  string `json:"url"`
-	} `json:"items"`
-}
-
 // ScanResult represents the result of a scan operation
 type ScanResult struct {
 	ProcessedFiles int       `json:"processed_files"`
@@ -32,3 +22,22 @@ type ScanRequestBody struct {
 	Repo  string   `json:"repo"`
 	Files []string `json:"files"`
 }
+
+// TrackRepoRequestBody defines the expected body in the request for the POST /repos endpoint
+type TrackRepoRequestBody struct {
+	Repo            string   `json:"repo"`
+	Files           []string `json:"files"`
+	IntervalSeconds int      `json:"interval_seconds"`
+}
+
+// QueryBody defines the expected body in the request for the /query endpoint
+type QueryBody struct {
+	Filters VulnerabilityFilter `json:"filters"`
+}
+
+// QueryResult is the paginated envelope returned by the /query endpoint
+type QueryResult struct {
+	Results    []Vulnerabality `json:"results"`
+	Total      int             `json:"total"`
+	NextOffset *int            `json:"next_offset,omitempty"`
+}
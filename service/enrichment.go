@@ -0,0 +1,335 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"souben/kai/repo"
+)
+
+// Enrichment holds the fields a VulnSource can fill in for a vulnerability record
+type Enrichment struct {
+	Cvss          float64
+	PublishedDate time.Time
+	FixedVersion  string
+	Link          string
+	RiskFactors   []string
+}
+
+// VulnSource looks up additional data for a vulnerability from an upstream feed
+type VulnSource interface {
+	Lookup(ctx context.Context, vuln repo.Vulnerabality) (Enrichment, error)
+}
+
+// ENRICHMENT_CACHE_TTL controls how long a cached enrichment record is reused before
+// the upstream source is queried again
+var ENRICHMENT_CACHE_TTL = time.Duration(getEnvAsIntOrDefault("ENRICHMENT_CACHE_TTL_HOURS", 24)) * time.Hour
+
+var (
+	OSV_API = getEnvOrDefault("OSV_API", "https://api.osv.dev/v1/query")
+	NVD_API = getEnvOrDefault("NVD_API", "https://services.nvd.nist.gov/rest/json/cves/2.0")
+)
+
+// sources maps a VULN_SOURCES name to its VulnSource implementation
+var sources = map[string]VulnSource{
+	"osv": &osvSource{client: &http.Client{Timeout: 10 * time.Second}},
+	"nvd": &nvdSource{client: &http.Client{Timeout: 10 * time.Second}, apiKey: os.Getenv("NVD_API_KEY")},
+}
+
+// enabledSources returns the VulnSource implementations requested via VULN_SOURCES
+func enabledSources() []VulnSource {
+	names := strings.Split(getEnvOrDefault("VULN_SOURCES", "osv"), ",")
+
+	var enabled []VulnSource
+	for _, name := range names {
+		if source, ok := sources[strings.TrimSpace(name)]; ok {
+			enabled = append(enabled, source)
+		}
+	}
+
+	return enabled
+}
+
+// enrichVulnerabilities fills in missing Cvss, PublishedDate, FixedVersion, Link and
+// RiskFactors by querying the enabled VulnSources, using a bounded worker pool of the
+// same CONCURRENCY size as processFilesInParallel's. db is used to cache/reuse enrichment lookups; it should
+// be the same dbRepository the caller is otherwise using, not the package global.
+func enrichVulnerabilities(ctx context.Context, db dbRepository, vulnerabilities []repo.Vulnerabality) []repo.Vulnerabality {
+	enabled := enabledSources()
+	if len(enabled) == 0 {
+		return vulnerabilities
+	}
+
+	var (
+		wg   sync.WaitGroup
+		pool = make(chan struct{}, CONCURRENCY)
+	)
+
+	for i := range vulnerabilities {
+		wg.Add(1)
+		pool <- struct{}{}
+
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-pool }()
+
+			enrichOne(ctx, db, &vulnerabilities[i], enabled)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return vulnerabilities
+}
+
+// enrichOne fills in the missing fields of a single vulnerability, preferring a cached
+// enrichment record over querying the enabled sources again.
+func enrichOne(ctx context.Context, db dbRepository, vuln *repo.Vulnerabality, enabled []VulnSource) {
+	if db == nil {
+		return
+	}
+
+	if rec, err := db.GetCachedEnrichment(ctx, vuln.ID, vuln.PackageName, vuln.CurrentVersion, ENRICHMENT_CACHE_TTL); err != nil {
+		Log.WithField("id", vuln.ID).WithError(err).Warn("enrichment cache lookup failed")
+	} else if rec != nil {
+		applyEnrichment(vuln, Enrichment{
+			Cvss:          rec.Cvss,
+			PublishedDate: rec.PublishedDate,
+			FixedVersion:  rec.FixedVersion,
+			Link:          rec.Link,
+			RiskFactors:   rec.RiskFactors,
+		})
+		return
+	}
+
+	for _, source := range enabled {
+		enrichment, err := source.Lookup(ctx, *vuln)
+		if err != nil {
+			Log.WithField("id", vuln.ID).WithError(err).Warn("enrichment lookup failed")
+			continue
+		}
+
+		applyEnrichment(vuln, enrichment)
+
+		rec := repo.EnrichmentRecord{
+			ID:             vuln.ID,
+			PackageName:    vuln.PackageName,
+			CurrentVersion: vuln.CurrentVersion,
+			Cvss:           vuln.Cvss,
+			PublishedDate:  vuln.PublishedDate,
+			FixedVersion:   vuln.FixedVersion,
+			Link:           vuln.Link,
+			RiskFactors:    vuln.RiskFactors,
+			FetchedAt:      time.Now(),
+		}
+		if err := db.SaveCachedEnrichment(ctx, rec); err != nil {
+			Log.WithField("id", vuln.ID).WithError(err).Warn("failed to cache enrichment")
+		}
+
+		return
+	}
+}
+
+// applyEnrichment fills in only the fields that are still unset on vuln
+func applyEnrichment(vuln *repo.Vulnerabality, e Enrichment) {
+	if vuln.Cvss == 0 {
+		vuln.Cvss = e.Cvss
+	}
+	if vuln.PublishedDate.IsZero() {
+		vuln.PublishedDate = e.PublishedDate
+	}
+	if vuln.FixedVersion == "" {
+		vuln.FixedVersion = e.FixedVersion
+	}
+	if vuln.Link == "" {
+		vuln.Link = e.Link
+	}
+	if len(vuln.RiskFactors) == 0 {
+		vuln.RiskFactors = e.RiskFactors
+	}
+}
+
+// osvSource looks up advisories from the OSV.dev REST API
+type osvSource struct {
+	client *http.Client
+}
+
+type osvQuery struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	Version string `json:"version"`
+}
+
+type osvResponse struct {
+	Vulns []struct {
+		ID       string `json:"id"`
+		Severity []struct {
+			Type  string `json:"type"`
+			Score string `json:"score"`
+		} `json:"severity"`
+		Published string `json:"published"`
+		Affected  []struct {
+			Ranges []struct {
+				Events []struct {
+					Fixed string `json:"fixed"`
+				} `json:"events"`
+			} `json:"ranges"`
+		} `json:"affected"`
+		References []struct {
+			URL string `json:"url"`
+		} `json:"references"`
+	} `json:"vulns"`
+}
+
+// Lookup queries OSV.dev for advisories matching the vulnerability's package and version
+func (s *osvSource) Lookup(ctx context.Context, vuln repo.Vulnerabality) (Enrichment, error) {
+	query := osvQuery{Version: vuln.CurrentVersion}
+	query.Package.Name = vuln.PackageName
+	query.Package.Ecosystem = getEnvOrDefault("OSV_ECOSYSTEM", "npm")
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		return Enrichment{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", OSV_API, bytes.NewReader(body))
+	if err != nil {
+		return Enrichment{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return Enrichment{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return Enrichment{}, fmt.Errorf("OSV API returned status %d", res.StatusCode)
+	}
+
+	var parsed osvResponse
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return Enrichment{}, err
+	}
+
+	if len(parsed.Vulns) == 0 {
+		return Enrichment{}, fmt.Errorf("no OSV advisory found for %s@%s", vuln.PackageName, vuln.CurrentVersion)
+	}
+
+	advisory := parsed.Vulns[0]
+	enrichment := Enrichment{Link: fmt.Sprintf("https://osv.dev/vulnerability/%s", advisory.ID)}
+
+	if t, err := time.Parse(time.RFC3339, advisory.Published); err == nil {
+		enrichment.PublishedDate = t
+	}
+
+	for _, sev := range advisory.Severity {
+		if sev.Type == "CVSS_V3" {
+			fmt.Sscanf(sev.Score, "%f", &enrichment.Cvss)
+		}
+	}
+
+	for _, affected := range advisory.Affected {
+		for _, r := range affected.Ranges {
+			for _, ev := range r.Events {
+				if ev.Fixed != "" {
+					enrichment.FixedVersion = ev.Fixed
+				}
+			}
+		}
+	}
+
+	for _, ref := range advisory.References {
+		enrichment.RiskFactors = append(enrichment.RiskFactors, ref.URL)
+	}
+
+	return enrichment, nil
+}
+
+// nvdSource looks up advisories from the NVD 2.0 API by CVE id
+type nvdSource struct {
+	client *http.Client
+	apiKey string
+}
+
+type nvdResponse struct {
+	Vulnerabilities []struct {
+		Cve struct {
+			ID        string `json:"id"`
+			Published string `json:"published"`
+			Metrics   struct {
+				CvssMetricV31 []struct {
+					CvssData struct {
+						BaseScore float64 `json:"baseScore"`
+					} `json:"cvssData"`
+				} `json:"cvssMetricV31"`
+			} `json:"metrics"`
+			References []struct {
+				URL string `json:"url"`
+			} `json:"references"`
+		} `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+// Lookup queries the NVD 2.0 API for the CVE matching the vulnerability's id
+func (s *nvdSource) Lookup(ctx context.Context, vuln repo.Vulnerabality) (Enrichment, error) {
+	if !strings.HasPrefix(vuln.ID, "CVE-") {
+		return Enrichment{}, fmt.Errorf("%s is not a CVE id", vuln.ID)
+	}
+
+	url := fmt.Sprintf("%s?cveId=%s", NVD_API, vuln.ID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return Enrichment{}, err
+	}
+	if s.apiKey != "" {
+		req.Header.Set("apiKey", s.apiKey)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return Enrichment{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return Enrichment{}, fmt.Errorf("NVD API returned status %d", res.StatusCode)
+	}
+
+	var parsed nvdResponse
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return Enrichment{}, err
+	}
+
+	if len(parsed.Vulnerabilities) == 0 {
+		return Enrichment{}, fmt.Errorf("no NVD entry found for %s", vuln.ID)
+	}
+
+	cve := parsed.Vulnerabilities[0].Cve
+	enrichment := Enrichment{Link: fmt.Sprintf("https://nvd.nist.gov/vuln/detail/%s", cve.ID)}
+
+	if t, err := time.Parse(time.RFC3339, cve.Published); err == nil {
+		enrichment.PublishedDate = t
+	}
+
+	if len(cve.Metrics.CvssMetricV31) > 0 {
+		enrichment.Cvss = cve.Metrics.CvssMetricV31[0].CvssData.BaseScore
+	}
+
+	for _, ref := range cve.References {
+		enrichment.RiskFactors = append(enrichment.RiskFactors, ref.URL)
+	}
+
+	return enrichment, nil
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"souben/kai/repo"
+)
+
+// Test osvSource.Lookup against a fake OSV.dev server
+func TestOsvSourceLookup(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCode  int
+		body        string
+		wantErr     bool
+		wantLink    string
+		wantCvss    float64
+		wantFixedTo string
+	}{
+		{
+			name:       "advisory found",
+			statusCode: http.StatusOK,
+			body: `{
+				"vulns": [
+					{
+						"id": "GHSA-xxxx-yyyy-zzzz",
+						"severity": [{"type": "CVSS_V3", "score": "7.5"}],
+						"published": "2024-01-01T00:00:00Z",
+						"affected": [{"ranges": [{"events": [{"fixed": "1.2.3"}]}]}],
+						"references": [{"url": "https://example.com/advisory"}]
+					}
+				]
+			}`,
+			wantLink:    "https://osv.dev/vulnerability/GHSA-xxxx-yyyy-zzzz",
+			wantCvss:    7.5,
+			wantFixedTo: "1.2.3",
+		},
+		{
+			name:       "no advisory found",
+			statusCode: http.StatusOK,
+			body:       `{"vulns": []}`,
+			wantErr:    true,
+		},
+		{
+			name:       "upstream error",
+			statusCode: http.StatusInternalServerError,
+			body:       `{}`,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			originalAPI := OSV_API
+			OSV_API = server.URL
+			defer func() { OSV_API = originalAPI }()
+
+			source := &osvSource{client: server.Client()}
+			enrichment, err := source.Lookup(context.Background(), repo.Vulnerabality{PackageName: "left-pad", CurrentVersion: "1.0.0"})
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Lookup() error = nil, want error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if enrichment.Link != tt.wantLink {
+				t.Errorf("Lookup() Link = %s, want %s", enrichment.Link, tt.wantLink)
+			}
+			if enrichment.Cvss != tt.wantCvss {
+				t.Errorf("Lookup() Cvss = %v, want %v", enrichment.Cvss, tt.wantCvss)
+			}
+			if enrichment.FixedVersion != tt.wantFixedTo {
+				t.Errorf("Lookup() FixedVersion = %s, want %s", enrichment.FixedVersion, tt.wantFixedTo)
+			}
+		})
+	}
+}
+
+// Test nvdSource.Lookup against a fake NVD server
+func TestNvdSourceLookup(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		statusCode int
+		body       string
+		wantErr    bool
+		wantCvss   float64
+	}{
+		{
+			name:    "non-CVE id is rejected without a request",
+			id:      "GHSA-xxxx-yyyy-zzzz",
+			wantErr: true,
+		},
+		{
+			name:       "CVE found",
+			id:         "CVE-2024-1234",
+			statusCode: http.StatusOK,
+			body: `{
+				"vulnerabilities": [
+					{
+						"cve": {
+							"id": "CVE-2024-1234",
+							"published": "2024-01-01T00:00:00Z",
+							"metrics": {"cvssMetricV31": [{"cvssData": {"baseScore": 9.8}}]},
+							"references": [{"url": "https://example.com/cve"}]
+						}
+					}
+				]
+			}`,
+			wantCvss: 9.8,
+		},
+		{
+			name:       "CVE not found",
+			id:         "CVE-2024-9999",
+			statusCode: http.StatusOK,
+			body:       `{"vulnerabilities": []}`,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			originalAPI := NVD_API
+			NVD_API = server.URL
+			defer func() { NVD_API = originalAPI }()
+
+			source := &nvdSource{client: server.Client()}
+			enrichment, err := source.Lookup(context.Background(), repo.Vulnerabality{ID: tt.id})
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Lookup() error = nil, want error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if enrichment.Cvss != tt.wantCvss {
+				t.Errorf("Lookup() Cvss = %v, want %v", enrichment.Cvss, tt.wantCvss)
+			}
+		})
+	}
+}
+
+// Test applyEnrichment only fills in fields that are still unset
+func TestApplyEnrichment(t *testing.T) {
+	published := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	enrichment := Enrichment{
+		Cvss:          9.8,
+		PublishedDate: published,
+		FixedVersion:  "2.0.0",
+		Link:          "https://example.com/new",
+		RiskFactors:   []string{"new risk"},
+	}
+
+	t.Run("fills empty fields", func(t *testing.T) {
+		vuln := &repo.Vulnerabality{}
+		applyEnrichment(vuln, enrichment)
+
+		if vuln.Cvss != 9.8 || vuln.FixedVersion != "2.0.0" || vuln.Link != "https://example.com/new" {
+			t.Errorf("applyEnrichment() did not fill empty fields: %+v", vuln)
+		}
+		if len(vuln.RiskFactors) != 1 || vuln.RiskFactors[0] != "new risk" {
+			t.Errorf("applyEnrichment() RiskFactors = %v, want [new risk]", vuln.RiskFactors)
+		}
+	})
+
+	t.Run("preserves already-set fields", func(t *testing.T) {
+		vuln := &repo.Vulnerabality{
+			Cvss:         5.0,
+			FixedVersion: "1.0.0",
+			Link:         "https://example.com/existing",
+			RiskFactors:  []string{"existing risk"},
+		}
+		applyEnrichment(vuln, enrichment)
+
+		if vuln.Cvss != 5.0 || vuln.FixedVersion != "1.0.0" || vuln.Link != "https://example.com/existing" {
+			t.Errorf("applyEnrichment() overwrote already-set fields: %+v", vuln)
+		}
+		if len(vuln.RiskFactors) != 1 || vuln.RiskFactors[0] != "existing risk" {
+			t.Errorf("applyEnrichment() overwrote RiskFactors: %v", vuln.RiskFactors)
+		}
+	})
+}
+
+// fakeVulnSource is a VulnSource test double with a scripted Lookup result
+type fakeVulnSource struct {
+	enrichment Enrichment
+	err        error
+}
+
+func (f *fakeVulnSource) Lookup(ctx context.Context, vuln repo.Vulnerabality) (Enrichment, error) {
+	return f.enrichment, f.err
+}
+
+// Test enrichOne's cache-hit, cache-miss and source-error branches
+func TestEnrichOne(t *testing.T) {
+	t.Run("cache hit applies the cached record without calling any source", func(t *testing.T) {
+		mockRepo := &MockRepository{
+			cachedEnrichment: &repo.EnrichmentRecord{Cvss: 4.2, Link: "https://example.com/cached"},
+		}
+
+		source := &fakeVulnSource{enrichment: Enrichment{Cvss: 9.9}}
+		vuln := &repo.Vulnerabality{ID: "CVE-2024-0001"}
+
+		enrichOne(context.Background(), mockRepo, vuln, []VulnSource{source})
+
+		if vuln.Cvss != 4.2 || vuln.Link != "https://example.com/cached" {
+			t.Errorf("enrichOne() = %+v, want cached values applied", vuln)
+		}
+	})
+
+	t.Run("cache miss queries the source and caches the result", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+
+		source := &fakeVulnSource{enrichment: Enrichment{Cvss: 9.9, Link: "https://example.com/fresh"}}
+		vuln := &repo.Vulnerabality{ID: "CVE-2024-0002"}
+
+		enrichOne(context.Background(), mockRepo, vuln, []VulnSource{source})
+
+		if vuln.Cvss != 9.9 || vuln.Link != "https://example.com/fresh" {
+			t.Errorf("enrichOne() = %+v, want source values applied", vuln)
+		}
+		if len(mockRepo.savedEnrichments) != 1 {
+			t.Fatalf("enrichOne() cached %d records, want 1", len(mockRepo.savedEnrichments))
+		}
+	})
+
+	t.Run("falls through to the next source on error", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+
+		failing := &fakeVulnSource{err: errors.New("upstream unavailable")}
+		working := &fakeVulnSource{enrichment: Enrichment{Cvss: 6.1}}
+		vuln := &repo.Vulnerabality{ID: "CVE-2024-0003"}
+
+		enrichOne(context.Background(), mockRepo, vuln, []VulnSource{failing, working})
+
+		if vuln.Cvss != 6.1 {
+			t.Errorf("enrichOne() Cvss = %v, want 6.1 from the fallback source", vuln.Cvss)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// Version is the running build's version, surfaced by GET /health
+const Version = "0.1.0"
+
+// startTime records when the process started, used to compute uptime for /health
+var startTime = time.Now()
+
+// HealthStatus is the payload returned by GET /health
+type HealthStatus struct {
+	Status  string `json:"status"`
+	DB      string `json:"db"`
+	Uptime  string `json:"uptime"`
+	Version string `json:"version"`
+}
+
+// Health pings the database and reports process uptime and version. The returned
+// bool is false if any dependency is unhealthy, so callers can map it to a 503.
+func Health(ctx context.Context) (HealthStatus, bool) {
+	status := HealthStatus{
+		Status:  "ok",
+		DB:      "ok",
+		Uptime:  time.Since(startTime).String(),
+		Version: Version,
+	}
+
+	if database == nil {
+		status.Status = "fail"
+		status.DB = "fail"
+		return status, false
+	}
+
+	if err := database.Ping(ctx); err != nil {
+		status.Status = "fail"
+		status.DB = "fail"
+		return status, false
+	}
+
+	return status, true
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"souben/kai/logging"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Log is the package-wide structured logger, configured from LOG_FORMAT and LOG_LEVEL.
+// It's the same instance clients and any other package use, via the logging package,
+// so every log line shares one format/level regardless of which package emits it.
+var Log = logging.Log
+
+// scanIDKey is the context key a scan's id is stored under so log entries derived
+// from that context can be correlated across goroutines
+type scanIDKey struct{}
+
+// WithScanID attaches scanID to ctx
+func WithScanID(ctx context.Context, scanID string) context.Context {
+	return context.WithValue(ctx, scanIDKey{}, scanID)
+}
+
+// loggerFromContext returns a log entry tagged with the scan_id stored in ctx, if any
+func loggerFromContext(ctx context.Context) *logrus.Entry {
+	if scanID, ok := ctx.Value(scanIDKey{}).(string); ok && scanID != "" {
+		return Log.WithField("scan_id", scanID)
+	}
+	return logrus.NewEntry(Log)
+}
+
+// newScanID generates a random id to tag a scan's log entries with
+func newScanID() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
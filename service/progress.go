@@ -0,0 +1,196 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"souben/kai/repo"
+)
+
+// ProgressEvent is a single point-in-time update on a running scan, suitable for
+// streaming to a client as-is (e.g. over SSE)
+type ProgressEvent struct {
+	Phase     string  `json:"phase"`
+	File      string  `json:"file,omitempty"`
+	Processed int     `json:"processed"`
+	Total     int     `json:"total"`
+	Percent   float64 `json:"percent"`
+	ElapsedMs int64   `json:"elapsed_ms"`
+}
+
+// Progress tracks how far a scan has gotten and publishes a ProgressEvent on every
+// phase transition, similar to KICS's ProgressBar: a total, a currentProgress
+// incremented atomically as work completes, and a channel events are sent on
+type Progress struct {
+	total     int
+	processed int64
+	start     time.Time
+	events    chan ProgressEvent
+}
+
+// NewProgress creates a Progress with an unknown total; setTotal fills it in once
+// the number of files to process is known
+func NewProgress() *Progress {
+	return &Progress{start: time.Now(), events: make(chan ProgressEvent, 64)}
+}
+
+// setTotal records how many files this scan will process. It must be called before
+// any goroutine starts reporting progress for this scan
+func (p *Progress) setTotal(total int) {
+	if p == nil {
+		return
+	}
+	p.total = total
+}
+
+// report publishes phase as the current phase for file without advancing the
+// completed count
+func (p *Progress) report(phase, file string) {
+	if p == nil {
+		return
+	}
+	p.send(phase, file, atomic.LoadInt64(&p.processed))
+}
+
+// complete atomically advances the completed count and publishes a "store" event
+// for file
+func (p *Progress) complete(file string) {
+	if p == nil {
+		return
+	}
+	processed := atomic.AddInt64(&p.processed, 1)
+	p.send("store", file, processed)
+}
+
+// send builds and publishes a ProgressEvent, dropping it if the events channel's
+// buffer is full so a slow or absent subscriber can never block the scan
+func (p *Progress) send(phase, file string, processed int64) {
+	var percent float64
+	if p.total > 0 {
+		percent = float64(processed) / float64(p.total) * 100
+	}
+
+	event := ProgressEvent{
+		Phase:     phase,
+		File:      file,
+		Processed: int(processed),
+		Total:     p.total,
+		Percent:   percent,
+		ElapsedMs: time.Since(p.start).Milliseconds(),
+	}
+
+	select {
+	case p.events <- event:
+	default:
+	}
+}
+
+// Events returns the channel ProgressEvents are published on. It is closed once
+// the scan finishes
+func (p *Progress) Events() <-chan ProgressEvent {
+	if p == nil {
+		return nil
+	}
+	return p.events
+}
+
+// Close signals that no further ProgressEvents will be published
+func (p *Progress) Close() {
+	if p != nil {
+		close(p.events)
+	}
+}
+
+// ScanStatus is the lifecycle state of an asynchronous scan job
+type ScanStatus string
+
+const (
+	ScanStatusRunning ScanStatus = "running"
+	ScanStatusDone    ScanStatus = "done"
+	ScanStatusFailed  ScanStatus = "failed"
+)
+
+// ScanJob tracks an asynchronous scan started via StartScan, so HTTP handlers can
+// poll its status/result or subscribe to its Progress
+type ScanJob struct {
+	ID       string
+	Progress *Progress
+
+	mu         sync.RWMutex
+	status     ScanStatus
+	result     *repo.ScanResult
+	err        string
+	finishedAt time.Time
+}
+
+// Status returns a snapshot of the job's current status, result (if done) and
+// error message (if failed)
+func (j *ScanJob) Status() (ScanStatus, *repo.ScanResult, string) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.status, j.result, j.err
+}
+
+// finish records the final outcome of the job
+func (j *ScanJob) finish(result *repo.ScanResult, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.finishedAt = time.Now()
+	if err != nil {
+		j.status = ScanStatusFailed
+		j.err = err.Error()
+		return
+	}
+
+	j.status = ScanStatusDone
+	j.result = result
+}
+
+// expired reports whether the job finished more than scanJobTTL ago, and so is
+// eligible for eviction from scanJobs. A job that's still running never expires.
+func (j *ScanJob) expired() bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.status != ScanStatusRunning && time.Since(j.finishedAt) > scanJobTTL
+}
+
+// scanJobTTL is how long a finished job's status/result stays available via
+// GetScanJob before it's evicted from scanJobs, so a long-lived process doesn't
+// accumulate one entry per scan forever.
+const scanJobTTL = 30 * time.Minute
+
+var (
+	scanJobs   = make(map[string]*ScanJob)
+	scanJobsMu sync.RWMutex
+)
+
+// registerScanJob creates and stores a new running ScanJob under a fresh id,
+// sweeping any finished jobs past scanJobTTL first
+func registerScanJob() *ScanJob {
+	job := &ScanJob{
+		ID:       newScanID(),
+		Progress: NewProgress(),
+		status:   ScanStatusRunning,
+	}
+
+	scanJobsMu.Lock()
+	for id, existing := range scanJobs {
+		if existing.expired() {
+			delete(scanJobs, id)
+		}
+	}
+	scanJobs[job.ID] = job
+	scanJobsMu.Unlock()
+
+	return job
+}
+
+// GetScanJob returns the job registered under id, if any
+func GetScanJob(id string) (*ScanJob, bool) {
+	scanJobsMu.RLock()
+	defer scanJobsMu.RUnlock()
+	job, ok := scanJobs[id]
+	return job, ok
+}
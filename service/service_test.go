@@ -2,17 +2,26 @@ package service
 
 import (
 	"context"
-	"net/http"
-	"net/http/httptest"
+	"souben/kai/clients"
+	"souben/kai/mockclients"
 	"souben/kai/repo"
 	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
 )
 
 // MockRepository is a mock implementation of dbRepository
 type MockRepository struct {
-	savedVulnerabilities []repo.Vulnerabality
-	vulnerabilitiesToReturn []repo.Vulnerabality
-	err error
+	savedVulnerabilities     []repo.Vulnerabality
+	vulnerabilitiesToReturn  []repo.Vulnerabality
+	vulnerabilitiesAfterScan []repo.Vulnerabality
+	getVulnerabilitiesCalls  int
+	cachedEnrichment         *repo.EnrichmentRecord
+	savedEnrichments         []repo.EnrichmentRecord
+	trackedRepos             []repo.TrackedRepo
+	updatedSchedules         []string
+	err                      error
 }
 
 func (m *MockRepository) SaveVulnerabilities(ctx context.Context, vulnerabilities []repo.Vulnerabality) error {
@@ -23,54 +32,88 @@ func (m *MockRepository) SaveVulnerabilities(ctx context.Context, vulnerabilitie
 	return nil
 }
 
-func (m *MockRepository) GetVulnerabilities(ctx context.Context, severity string) ([]repo.Vulnerabality, error) {
+func (m *MockRepository) GetVulnerabilities(ctx context.Context, filter repo.VulnerabilityFilter) ([]repo.Vulnerabality, int, error) {
 	if m.err != nil {
-		return nil, m.err
+		return nil, 0, m.err
+	}
+
+	// A second call (e.g. rescan's "after" read) sees vulnerabilitiesAfterScan if the
+	// test set it, so before/after can be made to differ like a real re-scan would.
+	list := m.vulnerabilitiesToReturn
+	if m.getVulnerabilitiesCalls > 0 && m.vulnerabilitiesAfterScan != nil {
+		list = m.vulnerabilitiesAfterScan
 	}
+	m.getVulnerabilitiesCalls++
 
 	var filtered []repo.Vulnerabality
-	for _, v := range m.vulnerabilitiesToReturn {
-		if v.Severity == severity {
+	for _, v := range list {
+		if len(filter.Severity) == 0 || containsString(filter.Severity, v.Severity) {
 			filtered = append(filtered, v)
 		}
 	}
-	return filtered, nil
+	return filtered, len(filtered), nil
 }
 
-func (m *MockRepository) Close() error {
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MockRepository) GetCachedEnrichment(ctx context.Context, id, packageName, currentVersion string, ttl time.Duration) (*repo.EnrichmentRecord, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.cachedEnrichment, nil
+}
+
+func (m *MockRepository) SaveCachedEnrichment(ctx context.Context, rec repo.EnrichmentRecord) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.savedEnrichments = append(m.savedEnrichments, rec)
 	return nil
 }
 
-// Test buildGitHubSearchURL function
-func TestBuildGitHubSearchURL(t *testing.T) {
-	tests := []struct {
-		name     string
-		repo     string
-		files    []string
-		expected string
-	}{
-		{
-			name:     "With repo and files",
-			repo:     "owner/repo",
-			files:    []string{"file1", "file2"},
-			expected: "https://api.github.com/search/code?q=repo:owner/repo+filename:file1.json+filename:file2.json",
-		},
-		{
-			name:     "With repo but no files",
-			repo:     "owner/repo",
-			files:    []string{},
-			expected: "https://api.github.com/search/code?q=repo:owner/repo+extension:json",
-		},
+func (m *MockRepository) SaveTrackedRepo(ctx context.Context, tracked repo.TrackedRepo) error {
+	return m.err
+}
+
+func (m *MockRepository) DeleteTrackedRepo(ctx context.Context, repoName string) error {
+	return m.err
+}
+
+func (m *MockRepository) ListTrackedRepos(ctx context.Context) ([]repo.TrackedRepo, error) {
+	if m.err != nil {
+		return nil, m.err
 	}
+	return m.trackedRepos, nil
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := buildGitHubSearchURL(tt.repo, tt.files)
-			if result != tt.expected {
-				t.Errorf("buildGitHubSearchURL() = %v, want %v", result, tt.expected)
-			}
-		})
+func (m *MockRepository) UpdateTrackedRepoSchedule(ctx context.Context, repoName string, lastScan, nextScan time.Time) error {
+	if m.err != nil {
+		return m.err
 	}
+	m.updatedSchedules = append(m.updatedSchedules, repoName)
+	return nil
+}
+
+func (m *MockRepository) WithScanLock(ctx context.Context, repoName string, fn func(ctx context.Context) error) error {
+	if m.err != nil {
+		return m.err
+	}
+	return fn(ctx)
+}
+
+func (m *MockRepository) Ping(ctx context.Context) error {
+	return m.err
+}
+
+func (m *MockRepository) Close() error {
+	return nil
 }
 
 // Test Filter function
@@ -106,14 +149,14 @@ func TestFilter(t *testing.T) {
 
 	// Test filtering HIGH severity
 	ctx := context.Background()
-	result, err := Filter(ctx, "HIGH")
+	result, total, err := Filter(ctx, repo.VulnerabilityFilter{Severity: []string{"HIGH"}})
 	if err != nil {
 		t.Fatalf("Filter() error = %v", err)
 	}
 
 	// Should return 2 HIGH vulnerabilities
-	if len(result) != 2 {
-		t.Errorf("Filter() returned %d results, want 2", len(result))
+	if len(result) != 2 || total != 2 {
+		t.Errorf("Filter() returned %d results (total %d), want 2", len(result), total)
 	}
 
 	// All should be HIGH severity
@@ -124,18 +167,18 @@ func TestFilter(t *testing.T) {
 	}
 
 	// Test filtering MEDIUM severity
-	result, err = Filter(ctx, "MEDIUM")
+	result, total, err = Filter(ctx, repo.VulnerabilityFilter{Severity: []string{"MEDIUM"}})
 	if err != nil {
 		t.Fatalf("Filter() error = %v", err)
 	}
 
 	// Should return 1 MEDIUM vulnerability
-	if len(result) != 1 {
-		t.Errorf("Filter() returned %d results, want 1", len(result))
+	if len(result) != 1 || total != 1 {
+		t.Errorf("Filter() returned %d results (total %d), want 1", len(result), total)
 	}
 
 	// Test filtering LOW severity (should return empty)
-	result, err = Filter(ctx, "LOW")
+	result, _, err = Filter(ctx, repo.VulnerabilityFilter{Severity: []string{"LOW"}})
 	if err != nil {
 		t.Fatalf("Filter() error = %v", err)
 	}
@@ -146,82 +189,60 @@ func TestFilter(t *testing.T) {
 	}
 }
 
-// Mock HTTP server for GitHub API tests
-func setupMockGitHubServer() *httptest.Server {
-	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Check if it's a search request
-		if r.URL.Path == "/search/code" {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{
-				"total_count": 1,
-				"items": [
+// Test Scanner.scan using a mocked SourceClient
+func TestScannerScan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mockclients.NewMockSourceClient(ctrl)
+	mockRepo := &MockRepository{}
+
+	ref := clients.FileRef{Name: "test.json", Path: "test.json", URL: "https://example.com/test.json"}
+	fileContent := []byte(`[
+		{
+			"scanResults": {
+				"vulnerabilities": [
 					{
-						"name": "test.json",
-						"path": "test.json",
-						"url": "/raw/test.json"
+						"id": "CVE-2024-1234",
+						"severity": "HIGH",
+						"cvss": 8.5,
+						"status": "fixed",
+						"package_name": "test-package",
+						"current_version": "1.0.0",
+						"fixed_version": "1.1.0",
+						"description": "Test vulnerability",
+						"published_date": "2024-01-01T00:00:00Z",
+						"link": "https://example.com/cve",
+						"risk_factors": ["Test Risk"]
 					}
 				]
-			}`))
-			return
-		}
-
-		// Check if it's a raw file request
-		if r.URL.Path == "/raw/test.json" {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`[
-				{
-					"scanResults": {
-						"vulnerabilities": [
-							{
-								"id": "CVE-2024-1234",
-								"severity": "HIGH",
-								"cvss": 8.5,
-								"status": "fixed",
-								"package_name": "test-package",
-								"current_version": "1.0.0",
-								"fixed_version": "1.1.0",
-								"description": "Test vulnerability",
-								"published_date": "2024-01-01T00:00:00Z",
-								"link": "https://example.com/cve",
-								"risk_factors": ["Test Risk"]
-							}
-						]
-					}
-				}
-			]`))
-			return
+			}
 		}
+	]`)
 
-		// Default response for unknown paths
-		w.WriteHeader(http.StatusNotFound)
-	}))
-}
+	mockClient.EXPECT().SearchFiles(gomock.Any(), "test/repo", []string(nil)).Return([]clients.FileRef{ref}, nil)
+	mockClient.EXPECT().FetchRaw(gomock.Any(), ref).Return(fileContent, nil)
 
-// Test searchGitHubFiles function
-func TestSearchGitHubFiles(t *testing.T) {
-	// Set up a mock HTTP server
-	server := setupMockGitHubServer()
-	defer server.Close()
+	// Disable enrichment sources so the test doesn't make real network calls. An empty
+	// VULN_SOURCES falls back to the default "osv", so name no known source instead.
+	t.Setenv("VULN_SOURCES", "none")
 
-	// Save and restore the original GITHUB_API
-	originalAPI := GITHUB_API
-	GITHUB_API = server.URL + "/search/code"
-	defer func() { GITHUB_API = originalAPI }()
+	scanner := NewScanner(mockClient, mockRepo)
 
-	// Test the search function
-	items, err := searchGitHubFiles(GITHUB_API+"?q=repo:test/repo", "test-token")
+	result, err := scanner.scan(context.Background(), "test/repo", nil, nil)
 	if err != nil {
-		t.Fatalf("searchGitHubFiles() error = %v", err)
+		t.Fatalf("scan() error = %v", err)
+	}
+
+	if result.ProcessedFiles != 1 {
+		t.Errorf("scan() ProcessedFiles = %d, want 1", result.ProcessedFiles)
 	}
 
-	// Check the results
-	if len(items) != 1 {
-		t.Fatalf("searchGitHubFiles() returned %d items, want 1", len(items))
+	if len(mockRepo.savedVulnerabilities) != 1 {
+		t.Fatalf("scan() saved %d vulnerabilities, want 1", len(mockRepo.savedVulnerabilities))
 	}
 
-	if items[0].Name != "test.json" {
-		t.Errorf("searchGitHubFiles() item name = %s, want test.json", items[0].Name)
+	if mockRepo.savedVulnerabilities[0].ID != "CVE-2024-1234" {
+		t.Errorf("scan() saved vulnerability ID = %s, want CVE-2024-1234", mockRepo.savedVulnerabilities[0].ID)
 	}
 }
\ No newline at end of file
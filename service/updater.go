@@ -0,0 +1,243 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"souben/kai/repo"
+)
+
+var (
+	UPDATER_INTERVAL = time.Duration(getEnvAsIntOrDefault("UPDATER_INTERVAL_SECONDS", 3600)) * time.Second
+	UPDATER_JITTER   = time.Duration(getEnvAsIntOrDefault("UPDATER_JITTER_SECONDS", 60)) * time.Second
+	WEBHOOK_URL      = getEnvOrDefault("WEBHOOK_URL", "")
+)
+
+// Stopper lets a caller ask a running goroutine to stop and wait until it has
+type Stopper struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewStopper creates a Stopper ready to be passed to Updater.Run
+func NewStopper() *Stopper {
+	return &Stopper{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Stop signals the running goroutine to exit and blocks until it confirms it has
+func (s *Stopper) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// Updater periodically re-scans every tracked repository so the vulnerability DB
+// stays fresh without clients re-POSTing /scan
+type Updater struct {
+	db dbRepository
+}
+
+// NewUpdater creates an Updater backed by the given database
+func NewUpdater(database dbRepository) *Updater {
+	return &Updater{db: database}
+}
+
+// Run ticks on UPDATER_INTERVAL (+/- UPDATER_JITTER) until stopper.Stop is called,
+// re-scanning any tracked repo whose schedule is due
+func (u *Updater) Run(ctx context.Context, stopper *Stopper) {
+	defer close(stopper.done)
+
+	for {
+		select {
+		case <-stopper.stop:
+			return
+		case <-time.After(nextTick()):
+			if err := u.runOnce(ctx); err != nil {
+				Log.WithError(err).Error("updater run failed")
+			}
+		}
+	}
+}
+
+// nextTick returns UPDATER_INTERVAL plus or minus a random jitter, so replicas don't
+// all wake up and hit Postgres at the same instant
+func nextTick() time.Duration {
+	if UPDATER_JITTER <= 0 {
+		return UPDATER_INTERVAL
+	}
+	offset := time.Duration(rand.Int63n(int64(2*UPDATER_JITTER))) - UPDATER_JITTER
+	return UPDATER_INTERVAL + offset
+}
+
+// runOnce re-scans every tracked repo whose next_scan has passed
+func (u *Updater) runOnce(ctx context.Context) error {
+	tracked, err := u.db.ListTrackedRepos(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list tracked repos: %w", err)
+	}
+
+	now := time.Now()
+	for _, t := range tracked {
+		if now.Before(t.NextScan) {
+			continue
+		}
+
+		t := t
+		err := u.db.WithScanLock(ctx, t.Repo, func(ctx context.Context) error {
+			return u.rescan(ctx, t)
+		})
+		if err != nil {
+			Log.WithField("repo", t.Repo).WithError(err).Error("failed to re-scan repo")
+		}
+	}
+
+	return nil
+}
+
+// rescan re-runs Scan for a tracked repo, advances its schedule and notifies the
+// configured webhook if new HIGH/CRITICAL vulnerabilities were found
+func (u *Updater) rescan(ctx context.Context, tracked repo.TrackedRepo) error {
+	highCritical := repo.VulnerabilityFilter{Severity: []string{"HIGH", "CRITICAL"}, Limit: -1}
+
+	before, _, err := u.db.GetVulnerabilities(ctx, highCritical)
+	if err != nil {
+		return err
+	}
+
+	result, err := Scan(tracked.Repo, tracked.Files)
+	if err != nil {
+		return err
+	}
+
+	after, _, err := u.db.GetVulnerabilities(ctx, highCritical)
+	if err != nil {
+		return err
+	}
+
+	newVulns := diffNewVulnerabilities(before, after)
+	if len(newVulns) > 0 {
+		notifyWebhook(tracked.Repo, newVulns)
+	}
+
+	now := time.Now()
+	nextScan := now.Add(time.Duration(tracked.Interval) * time.Second)
+	if err := u.db.UpdateTrackedRepoSchedule(ctx, tracked.Repo, now, nextScan); err != nil {
+		return err
+	}
+
+	Log.WithField("repo", tracked.Repo).Infof("re-scanned: processed %d files, %d new HIGH/CRITICAL vulns", result.ProcessedFiles, len(newVulns))
+	return nil
+}
+
+// diffNewVulnerabilities returns the vulnerabilities present in after but not in before
+func diffNewVulnerabilities(before, after []repo.Vulnerabality) []repo.Vulnerabality {
+	seen := make(map[string]bool, len(before))
+	for _, v := range before {
+		seen[v.ID+"|"+v.SourceFile] = true
+	}
+
+	var fresh []repo.Vulnerabality
+	for _, v := range after {
+		if !seen[v.ID+"|"+v.SourceFile] {
+			fresh = append(fresh, v)
+		}
+	}
+
+	return fresh
+}
+
+// webhookPayload is the JSON body POSTed to WEBHOOK_URL when new HIGH/CRITICAL vulns appear
+type webhookPayload struct {
+	Repo  string               `json:"repo"`
+	Count int                  `json:"count"`
+	Vulns []repo.Vulnerabality `json:"vulnerabilities"`
+}
+
+// notifyWebhook POSTs a summary of newly found vulnerabilities to WEBHOOK_URL, if configured
+func notifyWebhook(repoName string, newVulns []repo.Vulnerabality) {
+	if WEBHOOK_URL == "" {
+		return
+	}
+
+	body, err := json.Marshal(webhookPayload{Repo: repoName, Count: len(newVulns), Vulns: newVulns})
+	if err != nil {
+		Log.WithError(err).Error("failed to marshal webhook payload")
+		return
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Post(WEBHOOK_URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		Log.WithError(err).Error("failed to notify webhook")
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		Log.WithField("status_code", res.StatusCode).Warn("webhook returned non-2xx status")
+	}
+}
+
+// StartUpdater initializes the database if needed and starts the background updater,
+// returning a Stopper the caller should Stop() on shutdown
+func StartUpdater() (*Stopper, error) {
+	if database == nil {
+		if err := InitDatabase(); err != nil {
+			return nil, err
+		}
+	}
+
+	updater := NewUpdater(database)
+	stopper := NewStopper()
+
+	go updater.Run(context.Background(), stopper)
+
+	return stopper, nil
+}
+
+// TrackRepo registers a repository for periodic re-scanning at the given interval
+func TrackRepo(ctx context.Context, repoName string, files []string, interval time.Duration) error {
+	if database == nil {
+		if err := InitDatabase(); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	return database.SaveTrackedRepo(ctx, repo.TrackedRepo{
+		Repo:     repoName,
+		Files:    files,
+		Interval: int(interval.Seconds()),
+		LastScan: time.Time{},
+		NextScan: now.Add(interval),
+	})
+}
+
+// UntrackRepo stops a repository from being periodically re-scanned
+func UntrackRepo(ctx context.Context, repoName string) error {
+	if database == nil {
+		if err := InitDatabase(); err != nil {
+			return err
+		}
+	}
+
+	return database.DeleteTrackedRepo(ctx, repoName)
+}
+
+// ListTrackedRepos returns every repository currently tracked by the updater
+func ListTrackedRepos(ctx context.Context) ([]repo.TrackedRepo, error) {
+	if database == nil {
+		if err := InitDatabase(); err != nil {
+			return nil, err
+		}
+	}
+
+	return database.ListTrackedRepos(ctx)
+}
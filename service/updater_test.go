@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"souben/kai/repo"
+)
+
+// Test diffNewVulnerabilities identifies only vulnerabilities absent from before
+func TestDiffNewVulnerabilities(t *testing.T) {
+	before := []repo.Vulnerabality{
+		{ID: "CVE-2024-0001", SourceFile: "a.json"},
+		{ID: "CVE-2024-0002", SourceFile: "b.json"},
+	}
+	after := []repo.Vulnerabality{
+		{ID: "CVE-2024-0001", SourceFile: "a.json"},
+		{ID: "CVE-2024-0002", SourceFile: "b.json"},
+		{ID: "CVE-2024-0003", SourceFile: "c.json"},
+	}
+
+	fresh := diffNewVulnerabilities(before, after)
+	if len(fresh) != 1 || fresh[0].ID != "CVE-2024-0003" {
+		t.Errorf("diffNewVulnerabilities() = %+v, want only CVE-2024-0003", fresh)
+	}
+}
+
+// Test diffNewVulnerabilities treats the same id in a different SourceFile as new
+func TestDiffNewVulnerabilitiesDistinguishesSourceFile(t *testing.T) {
+	before := []repo.Vulnerabality{{ID: "CVE-2024-0001", SourceFile: "a.json"}}
+	after := []repo.Vulnerabality{{ID: "CVE-2024-0001", SourceFile: "b.json"}}
+
+	fresh := diffNewVulnerabilities(before, after)
+	if len(fresh) != 1 || fresh[0].SourceFile != "b.json" {
+		t.Errorf("diffNewVulnerabilities() = %+v, want the b.json entry", fresh)
+	}
+}
+
+// Test diffNewVulnerabilities returns nothing when before and after match
+func TestDiffNewVulnerabilitiesNoChange(t *testing.T) {
+	vulns := []repo.Vulnerabality{{ID: "CVE-2024-0001", SourceFile: "a.json"}}
+
+	fresh := diffNewVulnerabilities(vulns, vulns)
+	if len(fresh) != 0 {
+		t.Errorf("diffNewVulnerabilities() = %+v, want none", fresh)
+	}
+}
+
+// Test runOnce re-scans a due repo and tolerates a failing rescan instead of
+// aborting the whole run or propagating the error
+func TestUpdaterRunOnceToleratesFailedRescan(t *testing.T) {
+	mockRepo := &MockRepository{
+		trackedRepos: []repo.TrackedRepo{
+			{Repo: "test/repo", Interval: 3600, NextScan: time.Now().Add(-time.Minute)},
+		},
+	}
+	origDB := database
+	database = mockRepo
+	defer func() { database = origDB }()
+
+	// Scan() requires GITHUB_API_TOKEN; leaving it unset makes rescan fail so we can
+	// assert runOnce logs and continues rather than returning the error.
+	t.Setenv("GITHUB_API_TOKEN", "")
+
+	updater := NewUpdater(mockRepo)
+	if err := updater.runOnce(context.Background()); err != nil {
+		t.Fatalf("runOnce() error = %v, want nil (a failing rescan is logged, not returned)", err)
+	}
+
+	if len(mockRepo.updatedSchedules) != 0 {
+		t.Errorf("runOnce() updated the schedule for a repo whose rescan failed")
+	}
+}
+
+// Test runOnce skips a tracked repo whose NextScan hasn't arrived yet
+func TestUpdaterRunOnceSkipsNotYetDue(t *testing.T) {
+	mockRepo := &MockRepository{
+		trackedRepos: []repo.TrackedRepo{
+			{Repo: "test/repo", Interval: 3600, NextScan: time.Now().Add(time.Hour)},
+		},
+	}
+	origDB := database
+	database = mockRepo
+	defer func() { database = origDB }()
+
+	updater := NewUpdater(mockRepo)
+	if err := updater.runOnce(context.Background()); err != nil {
+		t.Fatalf("runOnce() error = %v, want nil", err)
+	}
+
+	if len(mockRepo.updatedSchedules) != 0 {
+		t.Errorf("runOnce() re-scanned a repo that wasn't due yet")
+	}
+}
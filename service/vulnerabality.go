@@ -1,21 +1,19 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
 	"os"
 	"sync"
 	"time"
 
+	"souben/kai/clients"
 	"souben/kai/repo"
 
 	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
 )
 
 // Helper functions for environment variables
@@ -38,8 +36,6 @@ func getEnvAsIntOrDefault(key string, defaultValue int) int {
 }
 
 var (
-	GITHUB_API  = getEnvOrDefault("GITHUB_API", "https://api.github.com/search/code")
-	MAX_RETRIES = getEnvAsIntOrDefault("MAX_RETRIES", 2)
 	CONCURRENCY = getEnvAsIntOrDefault("CONCURRENCY", 3)
 )
 
@@ -47,14 +43,22 @@ var (
 func init() {
 	// loads values from .env into the system
 	if err := godotenv.Load(); err != nil {
-		log.Print("No .env file found")
+		Log.Warn("No .env file found")
 	}
 }
 
 // dbRepository provides an interface for database operations
 type dbRepository interface {
 	SaveVulnerabilities(ctx context.Context, vulnerabilities []repo.Vulnerabality) error
-	GetVulnerabilities(ctx context.Context, severity string) ([]repo.Vulnerabality, error)
+	GetVulnerabilities(ctx context.Context, filter repo.VulnerabilityFilter) ([]repo.Vulnerabality, int, error)
+	GetCachedEnrichment(ctx context.Context, id, packageName, currentVersion string, ttl time.Duration) (*repo.EnrichmentRecord, error)
+	SaveCachedEnrichment(ctx context.Context, rec repo.EnrichmentRecord) error
+	SaveTrackedRepo(ctx context.Context, tracked repo.TrackedRepo) error
+	DeleteTrackedRepo(ctx context.Context, repoName string) error
+	ListTrackedRepos(ctx context.Context) ([]repo.TrackedRepo, error)
+	UpdateTrackedRepoSchedule(ctx context.Context, repoName string, lastScan, nextScan time.Time) error
+	WithScanLock(ctx context.Context, repoName string, fn func(ctx context.Context) error) error
+	Ping(ctx context.Context) error
 	Close() error
 }
 
@@ -92,9 +96,22 @@ func CloseDatabase() error {
 	return nil
 }
 
+// Scanner scans a source for vulnerability data files and stores what it finds.
+// It is decoupled from net/http via the clients.SourceClient interface, so other
+// sources (GitLab, a local filesystem, ...) can implement the same interface.
+type Scanner struct {
+	client clients.SourceClient
+	db     dbRepository
+}
+
+// NewScanner creates a Scanner backed by the given SourceClient and database
+func NewScanner(client clients.SourceClient, db dbRepository) *Scanner {
+	return &Scanner{client: client, db: db}
+}
+
 // Scan scans a GitHub repository for vulnerability data in JSON files
 func Scan(repoName string, filenames []string) (*repo.ScanResult, error) {
-	ctx := context.Background()
+	ctx := WithScanID(context.Background(), newScanID())
 
 	// Check if database is initialized
 	if database == nil {
@@ -109,116 +126,71 @@ func Scan(repoName string, filenames []string) (*repo.ScanResult, error) {
 		return nil, errors.New("GitHub token not found in environment variables")
 	}
 
-	// Construct the URL for the GitHub API call
-	url := buildGitHubSearchURL(repoName, filenames)
-
-	// Search for JSON files in the repository
-	items, err := searchGitHubFiles(url, token)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(items) == 0 {
-		log.Print("No items were found!")
-		return &repo.ScanResult{
-			ProcessedFiles: 0,
-			ScanTime:       time.Now(),
-			SourceRepo:     repoName,
-			SourceFiles:    []string{},
-		}, nil
-	}
-
-	// Process files concurrently
-	result, err := processFilesInParallel(ctx, items, token, repoName)
-	if err != nil {
-		return nil, err
-	}
-
-	return result, nil
+	scanner := NewScanner(clients.NewGitHubClient(token), database)
+	return scanner.scan(ctx, repoName, filenames, nil)
 }
 
-// buildGitHubSearchURL constructs the URL for the GitHub API search
-func buildGitHubSearchURL(repo string, filenames []string) string {
-	if repo == "" {
-		log.Fatal("You should provide a repository name!")
-	}
-
-	url := fmt.Sprintf("%s?q=repo:%s", GITHUB_API, repo)
+// StartScan kicks off a scan in the background and returns immediately with a
+// ScanJob the caller can poll (ScanJob.Status) or subscribe to (ScanJob.Progress)
+// for live updates, instead of blocking until every file has been processed
+func StartScan(repoName string, filenames []string) (*ScanJob, error) {
+	ctx := WithScanID(context.Background(), newScanID())
 
-	// If filenames were provided, search exactly for those files
-	for _, filename := range filenames {
-		url = fmt.Sprintf("%s+filename:%s.json", url, filename)
+	if database == nil {
+		if err := InitDatabase(); err != nil {
+			return nil, err
+		}
 	}
 
-	// If no filenames are provided, search for all JSON files within the repository
-	if len(filenames) == 0 {
-		url = fmt.Sprintf("%s+extension:json", url)
+	token := os.Getenv("GITHUB_API_TOKEN")
+	if token == "" {
+		return nil, errors.New("GitHub token not found in environment variables")
 	}
 
-	return url
-}
-
-// searchGitHubFiles performs a GitHub API search for files
-func searchGitHubFiles(url, token string) ([]struct {
-	Name string `json:"name"`
-	Path string `json:"path"`
-	URL  string `json:"url"`
-}, error) {
+	scanner := NewScanner(clients.NewGitHubClient(token), database)
+	job := registerScanJob()
 
-	var response repo.SearchResponse
+	go func() {
+		defer job.Progress.Close()
+		result, err := scanner.scan(ctx, repoName, filenames, job.Progress)
+		job.finish(result, err)
+	}()
 
-	// Implement retry logic for GitHub API calls
-	var err error
-	for attempt := 0; attempt <= MAX_RETRIES; attempt++ {
-		if attempt > 0 {
-			log.Printf("Retrying GitHub API call (attempt %d/%d)", attempt, MAX_RETRIES)
-			time.Sleep(time.Duration(attempt) * time.Second)
-		}
-
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			continue
-		}
-
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-
-		client := &http.Client{Timeout: 10 * time.Second}
-		res, err := client.Do(req)
-		if err != nil {
-			continue
-		}
+	return job, nil
+}
 
-		defer res.Body.Close()
+// scan searches repoName for vulnerability data files and processes whatever it
+// finds, reporting progress on progress if it isn't nil
+func (s *Scanner) scan(ctx context.Context, repoName string, filenames []string, progress *Progress) (*repo.ScanResult, error) {
+	logger := loggerFromContext(ctx).WithField("repo", repoName)
 
-		if res.StatusCode != http.StatusOK {
-			data, _ := io.ReadAll(res.Body)
-			err = fmt.Errorf("GitHub API returned status %d: %s", res.StatusCode, string(data))
-			continue
-		}
+	if repoName == "" {
+		return nil, errors.New("you should provide a repository name")
+	}
 
-		data, err := io.ReadAll(res.Body)
-		if err != nil {
-			continue
-		}
+	items, err := s.client.SearchFiles(ctx, repoName, filenames)
+	if err != nil {
+		return nil, err
+	}
 
-		err = json.Unmarshal(data, &response)
-		if err != nil {
-			continue
-		}
+	progress.setTotal(len(items))
+	progress.report("search", "")
 
-		// fetched succefully the files urls
-		return response.Items, nil
+	if len(items) == 0 {
+		logger.Info("No items were found!")
+		return &repo.ScanResult{
+			ProcessedFiles: 0,
+			ScanTime:       time.Now(),
+			SourceRepo:     repoName,
+			SourceFiles:    []string{},
+		}, nil
 	}
 
-	return nil, fmt.Errorf("failed to search GitHub files after %d attempts: %w", MAX_RETRIES+1, err)
+	return s.processFilesInParallel(ctx, items, repoName, progress)
 }
 
 // processFilesInParallel processes multiple files concurrently
-func processFilesInParallel(ctx context.Context, items []struct {
-	Name string `json:"name"`
-	Path string `json:"path"`
-	URL  string `json:"url"`
-}, token, repoName string) (*repo.ScanResult, error) {
+func (s *Scanner) processFilesInParallel(ctx context.Context, items []clients.FileRef, repoName string, progress *Progress) (*repo.ScanResult, error) {
 	var (
 		wg             sync.WaitGroup
 		mu             sync.Mutex
@@ -233,15 +205,11 @@ func processFilesInParallel(ctx context.Context, items []struct {
 		// The code will block here is we already have a number of goroutines == CONCURRENCY
 		pool <- struct{}{}
 
-		go func(item struct {
-			Name string `json:"name"`
-			Path string `json:"path"`
-			URL  string `json:"url"`
-		}) {
+		go func(item clients.FileRef) {
 			defer wg.Done()
 			defer func() { <-pool }() // once the goroutine is done, we will decrement the count of goroutines created
 
-			err := processFile(ctx, item, token, repoName)
+			err := s.processFile(ctx, item, repoName, progress)
 
 			mu.Lock()
 			defer mu.Unlock()
@@ -273,69 +241,24 @@ func processFilesInParallel(ctx context.Context, items []struct {
 	return result, nil
 }
 
-// processFile processes a single file and returns the vulnerabalities found
-func processFile(ctx context.Context, item struct {
-	Name string `json:"name"`
-	Path string `json:"path"`
-	URL  string `json:"url"`
-}, token, repoName string) error {
-	var vulnerabilities []repo.Vulnerabality
-
-	// Implement retry logic for GitHub API calls
-	var data []byte
-	var err error
-
-	for attempt := 0; attempt <= MAX_RETRIES; attempt++ {
-		if attempt > 0 {
-			log.Printf("Retrying file download for %s (attempt %d/%d)", item.Path, attempt, MAX_RETRIES)
-			time.Sleep(time.Duration(attempt) * time.Second)
-		}
-
-		req, err := http.NewRequest("GET", item.URL, bytes.NewBuffer([]byte{}))
-		if err != nil {
-			continue
-		}
-
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-		// The GitHub API will return a JSON response with the content as a field encoded in base64.
-		// We set the header to `application/vnd.github.v3.raw` so we can get the file's raw content
-		req.Header.Set("Accept", "application/vnd.github.v3.raw")
-
-		client := &http.Client{Timeout: 10 * time.Second}
-
-		res, err := client.Do(req)
-		if err != nil {
-			continue
-		}
-
-		defer res.Body.Close()
-
-		if res.StatusCode != http.StatusOK {
-			bodyData, _ := io.ReadAll(res.Body)
-			err = fmt.Errorf("GitHub API returned status %d: %s", res.StatusCode, string(bodyData))
-			continue
-		}
+// processFile downloads a single file and stores the vulnerabilities found in it,
+// reporting its progress through each phase (download, parse, store) on progress
+func (s *Scanner) processFile(ctx context.Context, item clients.FileRef, repoName string, progress *Progress) error {
+	defer progress.complete(item.Path)
 
-		data, err = io.ReadAll(res.Body)
-		if err != nil {
-			continue
-		}
-
-		// Successfully processed the file
-		break
-	}
+	var vulnerabilities []repo.Vulnerabality
 
+	progress.report("download", item.Path)
+	data, err := s.client.FetchRaw(ctx, item)
 	if err != nil {
-		return fmt.Errorf("failed to download file after %d attempts: %w", MAX_RETRIES+1, err)
+		return fmt.Errorf("failed to download file: %w", err)
 	}
 
 	// Parse the file content
+	progress.report("parse", item.Path)
 	var fileContent []repo.ScanPayloads
-	err = json.Unmarshal(data, &fileContent)
-	println(string(data))
-
-	if err != nil {
-		return fmt.Errorf("FFFFailed to parse file: %w %v", err, string(data))
+	if err := json.Unmarshal(data, &fileContent); err != nil {
+		return fmt.Errorf("failed to parse file: %w", err)
 	}
 
 	// Process the vulnerabilities
@@ -352,33 +275,36 @@ func processFile(ctx context.Context, item struct {
 		}
 	}
 
+	// Enrich vulnerabilities with data from upstream sources (OSV, NVD, ...) before storing them
+	vulnerabilities = enrichVulnerabilities(ctx, s.db, vulnerabilities)
+
 	// Store vulnerabilities in the database
 	if len(vulnerabilities) > 0 {
-
-		err = database.SaveVulnerabilities(ctx, vulnerabilities)
-		if err != nil {
+		if err := s.db.SaveVulnerabilities(ctx, vulnerabilities); err != nil {
 			return fmt.Errorf("failed to save vulnerabilities: %w", err)
 		}
 	}
 
+	loggerFromContext(ctx).WithFields(logrus.Fields{"repo": repoName, "file": item.Path}).Debug("file processed")
+
 	return nil
 }
 
-// Exract all the payloads based on a specific severity
-func Filter(ctx context.Context, severity string) ([]repo.Vulnerabality, error) {
+// Filter queries vulnerabilities matching filter, returning the matched page along
+// with the total number of matches so callers can paginate with limit/offset
+func Filter(ctx context.Context, filter repo.VulnerabilityFilter) ([]repo.Vulnerabality, int, error) {
 
 	// Check if the database is already set
 	if database == nil {
 		if err := InitDatabase(); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 	}
 
-	// Get the vulnerabilities based on severity
-	vulnerabilities, err := database.GetVulnerabilities(ctx, severity)
+	vulnerabilities, total, err := database.GetVulnerabilities(ctx, filter)
 	if err != nil {
-		return []repo.Vulnerabality{}, nil
+		return nil, 0, err
 	}
 
-	return vulnerabilities, nil
+	return vulnerabilities, total, nil
 }